@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"crypto/sha256"
@@ -8,18 +9,26 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	mrand "math/rand"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"text/tabwriter"
+	"text/template"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/btcsuite/btcutil/base58"
 	"github.com/r3labs/sse/v2"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	bolt "go.etcd.io/bbolt"
 )
 
 const (
@@ -34,18 +43,71 @@ const (
 	smoothPrintTickerInterval = 3 * time.Millisecond
 	smoothPrintBufferSize     = 1024
 	tabwriterPadding          = 2
+
+	authStyleBearer  = "bearer"
+	authStyleXAPIKey = "x-api-key"
+	authStyleNone    = "none"
+
+	perplexityProviderName = "perplexity"
+	openAIProviderName     = "openai"
+	anthropicProviderName  = "anthropic"
+	ollamaProviderName     = "ollama"
+	defaultProviderName    = perplexityProviderName
+
+	anthropicAPIVersion = "2023-06-01"
+
+	defaultFanOutConcurrency = 4
+	fanOutRedrawInterval     = 200 * time.Millisecond
+
+	defaultRetries   = 2
+	retryBackoffBase = 500 * time.Millisecond
+	retryBackoffMax  = 8 * time.Second
+
+	storeNameFS       = "fs"
+	storeNameBolt     = "bolt"
+	defaultStoreName  = storeNameFS
+	storeEnvVar       = "PERPLEXITY_STORE"
+	boltDBFileName    = "threads.db"
+	boltThreadsBucket = "threads"
+	boltIndexBucket   = "index"
+	boltTagsBucket    = "tags"
+	minShingleLen     = 3
 )
 
-type ChatCompletionRequest struct {
-	Model     string    `json:"model"`
-	Messages  []Message `json:"messages"`
-	Stream    bool      `json:"stream"`
-	MaxTokens *int      `json:"max_tokens,omitempty"`
+// outputFormat selects how `get`, `thread`, and `thread get` render their
+// results.
+type outputFormat string
+
+const (
+	outputText     outputFormat = "text"
+	outputJSON     outputFormat = "json"
+	outputNDJSON   outputFormat = "ndjson"
+	outputMarkdown outputFormat = "md"
+	outputTemplate outputFormat = "template"
+
+	defaultOutputFormat = outputText
+)
+
+// parseOutputFormat validates the --output flag's value.
+func parseOutputFormat(s string) (outputFormat, error) {
+	switch outputFormat(s) {
+	case outputText, outputJSON, outputNDJSON, outputMarkdown, outputTemplate:
+		return outputFormat(s), nil
+	default:
+		return "", fmt.Errorf(
+			"unknown --output format %q: want %q, %q, %q, %q, or %q",
+			s, outputText, outputJSON, outputNDJSON, outputMarkdown, outputTemplate,
+		)
+	}
 }
 
 type Message struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role      string   `json:"role"`
+	Content   string   `json:"content"`
+	Provider  string   `json:"provider,omitempty"`
+	Model     string   `json:"model,omitempty"`
+	Partial   bool     `json:"partial,omitempty"`
+	Citations []string `json:"citations,omitempty"`
 }
 
 type Thread struct {
@@ -59,22 +121,51 @@ type ThreadStore interface {
 	List() ([]*Thread, error)
 }
 
-type FSStore struct {
-	basePath string
+// ThreadSearcher is implemented by ThreadStore backends that maintain a
+// full-text index (currently only BoltStore). Callers like buildThreadCmd's
+// --filter type-assert for it and fall back to a linear substring scan
+// against store.List() when it's absent.
+type ThreadSearcher interface {
+	Search(query string) ([]*Thread, error)
 }
 
-type sseDelta struct {
-	Content string `json:"content"`
+// ThreadTagger is implemented by ThreadStore backends that support tagging
+// threads (currently only BoltStore).
+type ThreadTagger interface {
+	Tag(idPrefix, tag string) error
+	ListByTag(tag string) ([]*Thread, error)
+}
+
+// resolveStore constructs the ThreadStore backend named by --store/
+// PERPLEXITY_STORE. It's called fresh for each command invocation rather
+// than once at startup, since which backend to open isn't known until
+// cobra has parsed flags.
+func resolveStore(name string) (ThreadStore, error) {
+	switch name {
+	case storeNameFS:
+		return NewFSStore()
+	case storeNameBolt:
+		return NewBoltStore()
+	default:
+		return nil, fmt.Errorf("unknown store backend %q: want %q or %q", name, storeNameFS, storeNameBolt)
+	}
 }
 
-type sseChoice struct {
-	Delta        sseDelta `json:"delta"`
-	FinishReason string   `json:"finish_reason"`
+// closeStore closes store if it implements io.Closer (only BoltStore does),
+// logging any error under --debug the same way closeBody does for response
+// bodies.
+func closeStore(store ThreadStore) {
+	closer, ok := store.(io.Closer)
+	if !ok {
+		return
+	}
+	if err := closer.Close(); err != nil && debug {
+		fmt.Fprintf(os.Stderr, "Error closing store: %v\n", err)
+	}
 }
 
-type sseChunk struct {
-	Choices   []sseChoice `json:"choices"`
-	Citations []string    `json:"citations"`
+type FSStore struct {
+	basePath string
 }
 
 func NewFSStore() (*FSStore, error) {
@@ -153,6 +244,249 @@ func (fs *FSStore) List() ([]*Thread, error) {
 	return result, nil
 }
 
+// --- BoltDB-backed ThreadStore ---
+//
+// BoltStore keeps threads, a word-shingle full-text index, and a tag index
+// in a single BoltDB file under ~/.plexctl, rather than FSStore's one JSON
+// file per thread. It implements ThreadSearcher and ThreadTagger, which
+// FSStore doesn't.
+
+type BoltStore struct {
+	db *bolt.DB
+}
+
+// NewBoltStore opens (creating if necessary) the BoltDB file alongside
+// FSStore's threads directory and ensures its top-level buckets exist.
+func NewBoltStore() (*BoltStore, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	base := filepath.Join(home, ".plexctl")
+	if err := os.MkdirAll(base, dirPerm); err != nil {
+		return nil, err
+	}
+	return openBoltStore(filepath.Join(base, boltDBFileName))
+}
+
+// openBoltStore opens the BoltDB file at path and ensures its top-level
+// buckets exist, factored out of NewBoltStore so tests can point it at a
+// temp file instead of ~/.plexctl.
+func openBoltStore(path string) (*BoltStore, error) {
+	db, err := bolt.Open(path, filePerm, nil)
+	if err != nil {
+		return nil, fmt.Errorf("open bolt store: %w", err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range []string{boltThreadsBucket, boltIndexBucket, boltTagsBucket} {
+			if _, err := tx.CreateBucketIfNotExists([]byte(name)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("init bolt buckets: %w", err)
+	}
+	return &BoltStore{db: db}, nil
+}
+
+// Close releases the underlying BoltDB file lock. Callers type-assert for
+// io.Closer after resolveStore, the same way they type-assert for
+// ThreadSearcher/ThreadTagger, since FSStore doesn't need closing.
+func (b *BoltStore) Close() error {
+	return b.db.Close()
+}
+
+func (b *BoltStore) Load(idPrefix string) (*Thread, error) {
+	var th *Thread
+	err := b.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket([]byte(boltThreadsBucket)).Cursor()
+		prefix := []byte(idPrefix)
+		var match []byte
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			if match != nil {
+				return fmt.Errorf("prefix '%s' matched more than one thread", idPrefix)
+			}
+			match = append([]byte{}, v...)
+		}
+		if match == nil {
+			return errors.New("no matching thread found")
+		}
+		th = &Thread{}
+		return json.Unmarshal(match, th)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return th, nil
+}
+
+func (b *BoltStore) Save(th *Thread) error {
+	data, err := json.Marshal(th)
+	if err != nil {
+		return err
+	}
+	return b.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket([]byte(boltThreadsBucket)).Put([]byte(th.ID), data); err != nil {
+			return err
+		}
+		return indexThread(tx, th)
+	})
+}
+
+func (b *BoltStore) List() ([]*Thread, error) {
+	var result []*Thread
+	err := b.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(boltThreadsBucket)).ForEach(func(_, v []byte) error {
+			var th Thread
+			if json.Unmarshal(v, &th) != nil {
+				return nil
+			}
+			result = append(result, &th)
+			return nil
+		})
+	})
+	return result, err
+}
+
+// Search implements ThreadSearcher: it tokenizes query the same way Save
+// indexes message content via indexThread, unions the matching threads,
+// and orders them by how many distinct query tokens each one matched.
+func (b *BoltStore) Search(query string) ([]*Thread, error) {
+	counts := map[string]int{}
+	err := b.db.View(func(tx *bolt.Tx) error {
+		idx := tx.Bucket([]byte(boltIndexBucket))
+		for _, tok := range shingles(query) {
+			tb := idx.Bucket([]byte(tok))
+			if tb == nil {
+				continue
+			}
+			if err := tb.ForEach(func(id, _ []byte) error {
+				counts[string(id)]++
+				return nil
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(counts))
+	for id := range counts {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if counts[ids[i]] != counts[ids[j]] {
+			return counts[ids[i]] > counts[ids[j]]
+		}
+		return ids[i] < ids[j]
+	})
+
+	var result []*Thread
+	err = b.db.View(func(tx *bolt.Tx) error {
+		threads := tx.Bucket([]byte(boltThreadsBucket))
+		for _, id := range ids {
+			data := threads.Get([]byte(id))
+			if data == nil {
+				continue
+			}
+			var th Thread
+			if json.Unmarshal(data, &th) != nil {
+				continue
+			}
+			result = append(result, &th)
+		}
+		return nil
+	})
+	return result, err
+}
+
+// Tag implements ThreadTagger: it resolves idPrefix the same way Load does,
+// then records the tag in a per-tag bucket keyed by thread ID.
+func (b *BoltStore) Tag(idPrefix, tag string) error {
+	th, err := b.Load(idPrefix)
+	if err != nil {
+		return err
+	}
+	return b.db.Update(func(tx *bolt.Tx) error {
+		tb, err := tx.Bucket([]byte(boltTagsBucket)).CreateBucketIfNotExists([]byte(tag))
+		if err != nil {
+			return err
+		}
+		return tb.Put([]byte(th.ID), nil)
+	})
+}
+
+// ListByTag implements ThreadTagger.
+func (b *BoltStore) ListByTag(tag string) ([]*Thread, error) {
+	var result []*Thread
+	err := b.db.View(func(tx *bolt.Tx) error {
+		tb := tx.Bucket([]byte(boltTagsBucket)).Bucket([]byte(tag))
+		if tb == nil {
+			return nil
+		}
+		threads := tx.Bucket([]byte(boltThreadsBucket))
+		return tb.ForEach(func(id, _ []byte) error {
+			data := threads.Get(id)
+			if data == nil {
+				return nil
+			}
+			var th Thread
+			if json.Unmarshal(data, &th) != nil {
+				return nil
+			}
+			result = append(result, &th)
+			return nil
+		})
+	})
+	return result, err
+}
+
+// indexThread (re)indexes th's message content under the index bucket, one
+// nested bucket per token, so Search can look tokens up directly instead of
+// scanning every thread.
+func indexThread(tx *bolt.Tx, th *Thread) error {
+	idx := tx.Bucket([]byte(boltIndexBucket))
+	var content strings.Builder
+	for _, m := range th.Messages {
+		content.WriteString(m.Content)
+		content.WriteString(" ")
+	}
+	for _, tok := range shingles(content.String()) {
+		tb, err := idx.CreateBucketIfNotExists([]byte(tok))
+		if err != nil {
+			return err
+		}
+		if err := tb.Put([]byte(th.ID), nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// shingles lowercases s and splits it into unique word tokens, dropping
+// anything shorter than minShingleLen so common stopwords like "a" and "is"
+// don't blow up every token's posting list.
+func shingles(s string) []string {
+	seen := map[string]bool{}
+	var tokens []string
+	for _, word := range strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if len(word) < minShingleLen || seen[word] {
+			continue
+		}
+		seen[word] = true
+		tokens = append(tokens, word)
+	}
+	return tokens
+}
+
 func safeReadFile(basePath, filePath string) ([]byte, error) {
 	absFile, err := checkPath(basePath, filePath)
 	if err != nil {
@@ -209,127 +543,812 @@ func newThreadID(messages []Message) string {
 
 var debug bool
 
-func buildGetCmd(store ThreadStore, tokenPtr *string) *cobra.Command {
+// Delta is one increment of a streamed completion. A non-nil Err terminates
+// the stream; the channel is always closed afterward by the producer.
+type Delta struct {
+	Content   string
+	Citations []string
+	Err       error
+}
+
+// transientError marks a stream failure as worth retrying: a truncated SSE
+// stream or a transport-level read error, as opposed to a permanent failure
+// like a bad request or a stream that finished cleanly.
+type transientError struct {
+	err error
+}
+
+func (e *transientError) Error() string { return e.err.Error() }
+func (e *transientError) Unwrap() error { return e.err }
+
+func isTransient(err error) bool {
+	var te *transientError
+	return errors.As(err, &te)
+}
+
+// CompletionOptions carries the request knobs that are common across
+// providers; provider-specific defaults (e.g. model) live in providerConfig.
+type CompletionOptions struct {
+	Model     string
+	MaxTokens int
+}
+
+// Provider streams a completion for a thread from a specific backend.
+// Implementations own their wire format and push Deltas as they arrive.
+type Provider interface {
+	Name() string
+	StreamCompletion(
+		ctx context.Context,
+		th *Thread,
+		opts CompletionOptions,
+	) (<-chan Delta, error)
+	// ResolveModel returns model if non-empty, otherwise the provider's own
+	// configured default. Callers use this to record the model actually
+	// used rather than a possibly-empty --model flag value.
+	ResolveModel(model string) string
+}
+
+// providerConfig holds the resolved endpoint/auth/model settings for a
+// single provider, sourced from viper (and therefore from env vars or a
+// config file) with an optional CLI override for the token.
+type providerConfig struct {
+	endpoint  string
+	token     string
+	model     string
+	authStyle string
+}
+
+// loadProviderConfig seeds viper defaults for a provider section and binds
+// its token to an environment variable, then reads the resolved values.
+func loadProviderConfig(name, defaultEndpoint, defaultModel, tokenEnvVar, authStyle string) providerConfig {
+	key := func(field string) string { return "providers." + name + "." + field }
+	viper.SetDefault(key("endpoint"), defaultEndpoint)
+	viper.SetDefault(key("model"), defaultModel)
+	if tokenEnvVar != "" {
+		_ = viper.BindEnv(key("token"), tokenEnvVar)
+	}
+	return providerConfig{
+		endpoint:  viper.GetString(key("endpoint")),
+		token:     viper.GetString(key("token")),
+		model:     viper.GetString(key("model")),
+		authStyle: authStyle,
+	}
+}
+
+// newProvider resolves a Provider by name from viper-backed config,
+// applying tokenOverride (the --token flag) when set.
+func newProvider(name, tokenOverride string) (Provider, error) {
+	switch name {
+	case perplexityProviderName:
+		cfg, err := resolveProviderConfig(name,
+			"https://api.perplexity.ai/chat/completions",
+			"sonar", "PERPLEXITY_API_TOKEN", authStyleBearer, tokenOverride)
+		if err != nil {
+			return nil, err
+		}
+		return &PerplexityProvider{cfg: cfg}, nil
+	case openAIProviderName:
+		cfg, err := resolveProviderConfig(name,
+			"https://api.openai.com/v1/chat/completions",
+			"gpt-4o-mini", "OPENAI_API_KEY", authStyleBearer, tokenOverride)
+		if err != nil {
+			return nil, err
+		}
+		return &OpenAIProvider{cfg: cfg}, nil
+	case anthropicProviderName:
+		cfg, err := resolveProviderConfig(name,
+			"https://api.anthropic.com/v1/messages",
+			"claude-3-5-sonnet-latest", "ANTHROPIC_API_KEY", authStyleXAPIKey, tokenOverride)
+		if err != nil {
+			return nil, err
+		}
+		return &AnthropicProvider{cfg: cfg}, nil
+	case ollamaProviderName:
+		cfg := loadProviderConfig(name,
+			"http://localhost:11434/api/chat",
+			"llama3", "", authStyleNone)
+		return &OllamaProvider{cfg: cfg}, nil
+	default:
+		return nil, fmt.Errorf("unknown provider: %q", name)
+	}
+}
+
+// resolveProviderConfig loads a provider's config and applies the shared
+// "needs a token" validation that every HTTP-API-backed provider (all but
+// Ollama) requires.
+func resolveProviderConfig(
+	name, defaultEndpoint, defaultModel, tokenEnvVar, authStyle, tokenOverride string,
+) (providerConfig, error) {
+	cfg := loadProviderConfig(name, defaultEndpoint, defaultModel, tokenEnvVar, authStyle)
+	if tokenOverride != "" {
+		cfg.token = tokenOverride
+	}
+	if cfg.token == "" {
+		return providerConfig{}, fmt.Errorf(
+			"no token configured for provider %q: set --token or %s",
+			name, tokenEnvVar,
+		)
+	}
+	return cfg, nil
+}
+
+func setAuthHeader(req *http.Request, authStyle, token string) {
+	switch authStyle {
+	case authStyleBearer:
+		req.Header.Set("Authorization", "Bearer "+token)
+	case authStyleXAPIKey:
+		req.Header.Set("x-api-key", token)
+	case authStyleNone:
+	}
+}
+
+func buildGetCmd(storeNamePtr, tokenPtr, providerPtr *string, retryPtr *retryFlags) *cobra.Command {
 	var (
-		model    string
-		threadID string
-		maxToks  int
+		model       string
+		threadID    string
+		maxToks     int
+		queryFlags  []string
+		queriesFile string
+		concurrency int
+		output      string
+		tmplText    string
 	)
 	cmd := &cobra.Command{
-		Use:   "get <query>",
-		Short: "Get a completion for a query from Perplexity",
-		Args:  cobra.MinimumNArgs(1),
+		Use:   "get [query]",
+		Short: "Get a completion for a query from an LLM provider",
+		Args:  cobra.ArbitraryArgs,
 		RunE: func(_ *cobra.Command, args []string) error {
-			query := strings.Join(args, " ")
-			th, err := handleThreadLogic(store, threadID, query)
+			format, err := parseOutputFormat(output)
 			if err != nil {
 				return err
 			}
-			return streamCompletion(
-				*tokenPtr,
-				model,
-				th,
-				store,
-				maxToks,
-			)
+			store, err := resolveStore(*storeNamePtr)
+			if err != nil {
+				return err
+			}
+			defer closeStore(store)
+			queries, err := resolveFanOutQueries(queryFlags, queriesFile, args)
+			if err != nil {
+				return err
+			}
+			if len(queries) == 1 && len(queryFlags) == 0 && queriesFile == "" {
+				th, err := handleThreadLogic(store, threadID, queries[0])
+				if err != nil {
+					return err
+				}
+				provider, err := newProvider(*providerPtr, *tokenPtr)
+				if err != nil {
+					return err
+				}
+				render, err := newOutputRenderer(format, tmplText)
+				if err != nil {
+					return err
+				}
+				ctx, cancel := newInterruptibleContext()
+				defer cancel()
+				ctx, cancel2 := withOverallDeadline(ctx, retryPtr.Deadline)
+				defer cancel2()
+				return streamCompletion(
+					ctx,
+					provider,
+					model,
+					th,
+					store,
+					maxToks,
+					format == outputText,
+					retryPtr.toOptions(),
+					render,
+				)
+			}
+			if format != outputText {
+				return errors.New("--output formats other than text are not supported in fan-out mode")
+			}
+			return runFanOut(*tokenPtr, *providerPtr, model, queries, maxToks, concurrency, store, retryPtr)
 		},
 	}
-	cmd.Flags().StringVarP(&model, "model", "m", "sonar", "Model name")
+	cmd.Flags().StringVarP(&model, "model", "m", "", "Model name (defaults to the provider's default)")
 	cmd.Flags().StringVar(&threadID, "thread", "",
-		"Continue an existing thread by ID prefix")
+		"Continue an existing thread by ID prefix (single-query mode only)")
 	cmd.Flags().IntVar(&maxToks, "max-tokens", 0, "Max tokens in response")
+	cmd.Flags().StringArrayVar(&queryFlags, "query", nil,
+		"Query to run; repeatable to fan out multiple queries concurrently")
+	cmd.Flags().StringVar(&queriesFile, "queries-file", "",
+		"File of newline-delimited queries to run concurrently")
+	cmd.Flags().IntVar(&concurrency, "concurrency", defaultFanOutConcurrency,
+		"Max number of queries to run concurrently in fan-out mode")
+	cmd.Flags().StringVar(&output, "output", string(defaultOutputFormat),
+		"Output format: text, json, ndjson, md, or template (single-query mode only)")
+	cmd.Flags().StringVar(&tmplText, "template", "",
+		"Go template to render with --output template")
 	return cmd
 }
 
-func buildThreadGetCmd(store ThreadStore) *cobra.Command {
-	return &cobra.Command{
+// resolveFanOutQueries merges --query flags and --queries-file into the
+// list of queries to run. Positional args are joined into one query and
+// used only when neither of those yields any query. A single resulting
+// query with no explicit flags is treated as the plain `get <query>` path.
+func resolveFanOutQueries(queryFlags []string, queriesFile string, args []string) ([]string, error) {
+	queries := append([]string{}, queryFlags...)
+	if queriesFile != "" {
+		fileQueries, err := readQueriesFile(queriesFile)
+		if err != nil {
+			return nil, err
+		}
+		queries = append(queries, fileQueries...)
+	}
+	if len(queries) == 0 {
+		if len(args) == 0 {
+			return nil, errors.New("provide a query, --query, or --queries-file")
+		}
+		queries = []string{strings.Join(args, " ")}
+	}
+	return queries, nil
+}
+
+func readQueriesFile(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read queries file: %w", err)
+	}
+	var queries []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		queries = append(queries, line)
+	}
+	return queries, nil
+}
+
+func buildThreadGetCmd(storeNamePtr *string) *cobra.Command {
+	var output, tmplText string
+	cmd := &cobra.Command{
 		Use:   "get <threadid>",
 		Short: "Get a thread's messages",
 		Args:  cobra.ExactArgs(1),
 		RunE: func(_ *cobra.Command, args []string) error {
-			th, err := store.Load(args[0])
+			format, err := parseOutputFormat(output)
 			if err != nil {
 				return err
 			}
-			idPrefix := th.ID
-			if len(idPrefix) > idTruncLen {
-				idPrefix = idPrefix[:idTruncLen]
-			}
-			fmt.Printf("THREAD: %s\n\n", idPrefix)
-			for i, msg := range th.Messages {
-				fmt.Printf(
-					"[%d] %s:\n%s\n\n",
-					i,
-					strings.ToUpper(msg.Role),
-					msg.Content,
-				)
+			store, err := resolveStore(*storeNamePtr)
+			if err != nil {
+				return err
 			}
-			return nil
+			defer closeStore(store)
+			th, err := store.Load(args[0])
+			if err != nil {
+				return err
+			}
+			return printThread(th, format, tmplText)
 		},
 	}
+	cmd.Flags().StringVar(&output, "output", string(defaultOutputFormat),
+		"Output format: text, json, ndjson, md, or template")
+	cmd.Flags().StringVar(&tmplText, "template", "",
+		"Go template to render with --output template")
+	return cmd
+}
+
+// printThread renders a single thread's messages in the requested format.
+func printThread(th *Thread, format outputFormat, tmplText string) error {
+	switch format {
+	case outputText:
+		return printThreadText(th)
+	case outputJSON:
+		return json.NewEncoder(os.Stdout).Encode(th)
+	case outputNDJSON:
+		enc := json.NewEncoder(os.Stdout)
+		for _, msg := range th.Messages {
+			if err := enc.Encode(msg); err != nil {
+				return err
+			}
+		}
+		return nil
+	case outputMarkdown:
+		return printThreadMarkdown(th)
+	case outputTemplate:
+		if tmplText == "" {
+			return errors.New("--output template requires --template")
+		}
+		tmpl, err := template.New("plexctl").Parse(tmplText)
+		if err != nil {
+			return fmt.Errorf("parse --template: %w", err)
+		}
+		return tmpl.Execute(os.Stdout, th)
+	default:
+		return fmt.Errorf("unknown --output format %q", format)
+	}
+}
+
+func printThreadText(th *Thread) error {
+	idPrefix := th.ID
+	if len(idPrefix) > idTruncLen {
+		idPrefix = idPrefix[:idTruncLen]
+	}
+	fmt.Printf("THREAD: %s\n\n", idPrefix)
+	for i, msg := range th.Messages {
+		fmt.Printf(
+			"[%d] %s:\n%s\n\n",
+			i,
+			roleHeader(msg),
+			msg.Content,
+		)
+	}
+	return nil
+}
+
+// printThreadMarkdown bolds assistant role headers and renders each
+// message's citations as `[n]: url` footnotes at the end of the thread.
+func printThreadMarkdown(th *Thread) error {
+	idPrefix := th.ID
+	if len(idPrefix) > idTruncLen {
+		idPrefix = idPrefix[:idTruncLen]
+	}
+	fmt.Printf("# Thread %s\n\n", idPrefix)
+
+	var footnotes []string
+	for _, msg := range th.Messages {
+		header := strings.ToUpper(msg.Role)
+		if msg.Role == "assistant" {
+			header = "**" + header + "**"
+		}
+		fmt.Printf("%s:\n\n%s\n\n", header, msg.Content)
+		footnotes = append(footnotes, msg.Citations...)
+	}
+	if len(footnotes) > 0 {
+		fmt.Println("---")
+		for i, c := range footnotes {
+			fmt.Printf("[%d]: %s\n", i+1, c)
+		}
+	}
+	return nil
+}
+
+// roleHeader renders a message's role, tagging assistant turns with the
+// provider/model that produced them when that metadata was recorded, and
+// flagging ones that never finished streaming as partial.
+func roleHeader(msg Message) string {
+	role := strings.ToUpper(msg.Role)
+	if msg.Provider != "" || msg.Model != "" {
+		role = fmt.Sprintf("%s (%s/%s)", role, msg.Provider, msg.Model)
+	}
+	if msg.Partial {
+		role += " [partial]"
+	}
+	return role
 }
 
-func buildThreadCmd(store ThreadStore) *cobra.Command {
-	var filter string
+func buildThreadCmd(storeNamePtr *string) *cobra.Command {
+	var filter, tag, output, tmplText string
 	cmd := &cobra.Command{
 		Use:   "thread",
 		Short: "Manage threads",
 		RunE: func(_ *cobra.Command, _ []string) error {
-			all, err := store.List()
+			format, err := parseOutputFormat(output)
 			if err != nil {
 				return err
 			}
-			w := tabwriter.NewWriter(
-				os.Stdout,
-				0,
-				0,
-				tabwriterPadding,
-				' ',
-				0,
-			)
-
-			if _, err = fmt.Fprintln(w, "THREAD ID\tFIRST USER MESSAGE"); err != nil {
+			store, err := resolveStore(*storeNamePtr)
+			if err != nil {
 				return err
 			}
+			defer closeStore(store)
 
-			for _, th := range all {
-				if len(th.Messages) == 0 {
-					continue
-				}
-				id := th.ID
-				if len(id) > idTruncLen {
-					id = id[:idTruncLen]
+			var all []*Thread
+			switch {
+			case tag != "":
+				tagger, ok := store.(ThreadTagger)
+				if !ok {
+					return fmt.Errorf("--tag requires a store that supports tagging (try --store %s)", storeNameBolt)
 				}
-				first := th.Messages[0].Content
-				if filter == "" ||
-					strings.Contains(th.ID, filter) ||
-					strings.Contains(first, filter) {
-					if _, err = fmt.Fprintf(w, "%s\t%s\n",
-						id, snippet(first)); err != nil {
-						return err
-					}
+				all, err = tagger.ListByTag(tag)
+			case filter != "":
+				if searcher, ok := store.(ThreadSearcher); ok {
+					all, err = searcher.Search(filter)
+				} else {
+					all, err = filterThreadsBySubstring(store, filter)
 				}
+			default:
+				all, err = store.List()
+			}
+			if err != nil {
+				return err
 			}
-			return w.Flush()
+			return printThreadList(all, format, tmplText)
 		},
 	}
 	cmd.Flags().StringVar(&filter, "filter", "",
-		"Filter by ID or content substring")
-	cmd.AddCommand(buildThreadGetCmd(store))
+		"Filter by ID or content substring; with a full-text index (--store bolt), matches indexed message words instead")
+	cmd.Flags().StringVar(&tag, "tag", "",
+		"List only threads with this tag (requires --store "+storeNameBolt+")")
+	cmd.Flags().StringVar(&output, "output", string(defaultOutputFormat),
+		"Output format: text, json, ndjson, md, or template")
+	cmd.Flags().StringVar(&tmplText, "template", "",
+		"Go template to render with --output template")
+	cmd.AddCommand(buildThreadGetCmd(storeNamePtr))
+	cmd.AddCommand(buildThreadSearchCmd(storeNamePtr))
+	cmd.AddCommand(buildThreadTagCmd(storeNamePtr))
+	cmd.AddCommand(buildThreadMigrateCmd())
 	return cmd
 }
 
-func handleThreadLogic(
-	store ThreadStore,
-	idPrefix, userQuery string,
-) (*Thread, error) {
-	if idPrefix != "" {
-		th, err := store.Load(idPrefix)
-		if err != nil {
-			return nil, err
+// filterThreadsBySubstring is the fallback for --filter against a
+// ThreadStore that doesn't implement ThreadSearcher (i.e. FSStore).
+func filterThreadsBySubstring(store ThreadStore, filter string) ([]*Thread, error) {
+	all, err := store.List()
+	if err != nil {
+		return nil, err
+	}
+	var matched []*Thread
+	for _, th := range all {
+		if len(th.Messages) == 0 {
+			continue
+		}
+		if strings.Contains(th.ID, filter) || strings.Contains(th.Messages[0].Content, filter) {
+			matched = append(matched, th)
 		}
-		th.Messages = append(th.Messages, Message{"user", userQuery})
-		return th, nil
+	}
+	return matched, nil
+}
+
+// printThreadList renders a thread summary for `thread` in the requested
+// format; `thread search` prints with printThreadListText directly.
+func printThreadList(all []*Thread, format outputFormat, tmplText string) error {
+	switch format {
+	case outputText:
+		return printThreadListText(all)
+	case outputJSON:
+		return json.NewEncoder(os.Stdout).Encode(all)
+	case outputNDJSON:
+		enc := json.NewEncoder(os.Stdout)
+		for _, th := range all {
+			if err := enc.Encode(th); err != nil {
+				return err
+			}
+		}
+		return nil
+	case outputMarkdown:
+		return printThreadListMarkdown(all)
+	case outputTemplate:
+		if tmplText == "" {
+			return errors.New("--output template requires --template")
+		}
+		tmpl, err := template.New("plexctl").Parse(tmplText)
+		if err != nil {
+			return fmt.Errorf("parse --template: %w", err)
+		}
+		return tmpl.Execute(os.Stdout, all)
+	default:
+		return fmt.Errorf("unknown --output format %q", format)
+	}
+}
+
+func printThreadListText(all []*Thread) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, tabwriterPadding, ' ', 0)
+	if _, err := fmt.Fprintln(w, "THREAD ID\tFIRST USER MESSAGE"); err != nil {
+		return err
+	}
+	for _, th := range all {
+		if len(th.Messages) == 0 {
+			continue
+		}
+		id := th.ID
+		if len(id) > idTruncLen {
+			id = id[:idTruncLen]
+		}
+		if _, err := fmt.Fprintf(w, "%s\t%s\n", id, snippet(th.Messages[0].Content)); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
+
+func printThreadListMarkdown(all []*Thread) error {
+	fmt.Println("| Thread ID | First User Message |")
+	fmt.Println("| --- | --- |")
+	for _, th := range all {
+		if len(th.Messages) == 0 {
+			continue
+		}
+		id := th.ID
+		if len(id) > idTruncLen {
+			id = id[:idTruncLen]
+		}
+		fmt.Printf("| %s | %s |\n", id, snippet(th.Messages[0].Content))
+	}
+	return nil
+}
+
+// buildThreadSearchCmd exposes BoltStore's full-text index directly,
+// independent of `thread --filter`.
+func buildThreadSearchCmd(storeNamePtr *string) *cobra.Command {
+	return &cobra.Command{
+		Use:   "search <query>",
+		Short: "Full-text search over thread message content",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			store, err := resolveStore(*storeNamePtr)
+			if err != nil {
+				return err
+			}
+			defer closeStore(store)
+			searcher, ok := store.(ThreadSearcher)
+			if !ok {
+				return fmt.Errorf("search requires a store with a full-text index (try --store %s)", storeNameBolt)
+			}
+			results, err := searcher.Search(args[0])
+			if err != nil {
+				return err
+			}
+			return printThreadListText(results)
+		},
+	}
+}
+
+// buildThreadTagCmd attaches a tag to a thread, resolved by ID prefix the
+// same way `thread get` resolves one.
+func buildThreadTagCmd(storeNamePtr *string) *cobra.Command {
+	return &cobra.Command{
+		Use:   "tag <threadid> <tag>",
+		Short: "Tag a thread",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(_ *cobra.Command, args []string) error {
+			store, err := resolveStore(*storeNamePtr)
+			if err != nil {
+				return err
+			}
+			defer closeStore(store)
+			tagger, ok := store.(ThreadTagger)
+			if !ok {
+				return fmt.Errorf("tag requires a store that supports tagging (try --store %s)", storeNameBolt)
+			}
+			if err := tagger.Tag(args[0], args[1]); err != nil {
+				return err
+			}
+			fmt.Printf("tagged %s with %q\n", args[0], args[1])
+			return nil
+		},
+	}
+}
+
+// buildThreadMigrateCmd bulk-loads every thread from one store backend into
+// another, preserving IDs (Save is keyed by th.ID regardless of backend).
+// --from/--to are independent of the global --store flag, since a migration
+// inherently spans two backends.
+func buildThreadMigrateCmd() *cobra.Command {
+	var from, to string
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Bulk-load threads from one store backend into another",
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			src, err := resolveStore(from)
+			if err != nil {
+				return fmt.Errorf("resolve --from store: %w", err)
+			}
+			defer closeStore(src)
+			dst, err := resolveStore(to)
+			if err != nil {
+				return fmt.Errorf("resolve --to store: %w", err)
+			}
+			defer closeStore(dst)
+
+			threads, err := src.List()
+			if err != nil {
+				return err
+			}
+			for _, th := range threads {
+				if err := dst.Save(th); err != nil {
+					return fmt.Errorf("save thread %s: %w", th.ID, err)
+				}
+			}
+			fmt.Printf("migrated %d thread(s) from %s to %s\n", len(threads), from, to)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&from, "from", storeNameFS, "Source store backend: fs or bolt")
+	cmd.Flags().StringVar(&to, "to", storeNameBolt, "Destination store backend: fs or bolt")
+	return cmd
+}
+
+func buildChatCmd(storeNamePtr, tokenPtr, providerPtr *string, retryPtr *retryFlags) *cobra.Command {
+	var (
+		model    string
+		threadID string
+		maxToks  int
+	)
+	cmd := &cobra.Command{
+		Use:   "chat",
+		Short: "Open an interactive REPL against an LLM provider",
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			store, err := resolveStore(*storeNamePtr)
+			if err != nil {
+				return err
+			}
+			defer closeStore(store)
+			return runChatREPL(*tokenPtr, providerPtr, &model, &threadID, &maxToks, store, retryPtr)
+		},
+	}
+	cmd.Flags().StringVarP(&model, "model", "m", "", "Model name (defaults to the provider's default)")
+	cmd.Flags().StringVar(&threadID, "thread", "",
+		"Continue an existing thread by ID prefix")
+	cmd.Flags().IntVar(&maxToks, "max-tokens", 0, "Max tokens in response")
+	return cmd
+}
+
+// runChatREPL drives the interactive chat loop: it reads a line at a time,
+// dispatches slash-commands, and otherwise streams a completion for the
+// line as a new thread turn, saving to store after each turn.
+func runChatREPL(
+	token string,
+	providerName, model, threadID *string,
+	maxToks *int,
+	store ThreadStore,
+	retryPtr *retryFlags,
+) error {
+	th, err := newOrLoadChatThread(store, *threadID)
+	if err != nil {
+		return err
+	}
+
+	showCitations := true
+	fmt.Println("plexctl chat - /quit to exit, /help for commands")
+	scanner := bufio.NewScanner(os.Stdin)
+	for {
+		fmt.Print("> ")
+		if !scanner.Scan() {
+			return scanner.Err()
+		}
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "/") {
+			newTh, quit, cerr := handleSlashCommand(
+				line, th, store, providerName, model, threadID, maxToks, &showCitations,
+			)
+			if cerr != nil {
+				fmt.Fprintln(os.Stderr, "Error:", cerr)
+				continue
+			}
+			if quit {
+				return nil
+			}
+			if newTh != nil {
+				th = newTh
+			}
+			continue
+		}
+
+		th.Messages = append(th.Messages, Message{Role: "user", Content: line})
+		if th.ID == "" {
+			th.ID = newThreadID(th.Messages)
+		}
+
+		provider, err := newProvider(*providerName, token)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			continue
+		}
+
+		ctx, cancel := newInterruptibleContext()
+		ctx, cancel2 := withOverallDeadline(ctx, retryPtr.Deadline)
+		err = streamCompletion(
+			ctx, provider, *model, th, store, *maxToks, showCitations, retryPtr.toOptions(), textOutputRenderer{},
+		)
+		cancel2()
+		cancel()
+		if err != nil {
+			if errors.Is(err, context.Canceled) {
+				fmt.Fprintln(os.Stderr, "\n(completion cancelled)")
+				continue
+			}
+			fmt.Fprintln(os.Stderr, "Error:", err)
+		}
+	}
+}
+
+func newOrLoadChatThread(store ThreadStore, threadID string) (*Thread, error) {
+	if threadID == "" {
+		return &Thread{}, nil
+	}
+	return store.Load(threadID)
+}
+
+// handleSlashCommand dispatches a single REPL command. It returns a non-nil
+// *Thread when the active thread was replaced (e.g. /thread, /reset).
+func handleSlashCommand(
+	line string,
+	th *Thread,
+	store ThreadStore,
+	providerName, model, threadID *string,
+	maxToks *int,
+	showCitations *bool,
+) (*Thread, bool, error) {
+	fields := strings.Fields(line)
+	cmd := fields[0]
+	arg := ""
+	if len(fields) > 1 {
+		arg = strings.Join(fields[1:], " ")
+	}
+
+	switch cmd {
+	case "/help":
+		fmt.Println("Commands:")
+		fmt.Println("  /thread <id>      Continue an existing thread by ID prefix")
+		fmt.Println("  /provider <name>  Switch provider")
+		fmt.Println("  /model <name>     Switch model")
+		fmt.Println("  /max-tokens <N>   Set max response tokens")
+		fmt.Println("  /save             Save the current thread")
+		fmt.Println("  /reset            Start a new, empty thread")
+		fmt.Println("  /cite             Toggle printing citations")
+		fmt.Println("  /quit             Exit the REPL")
+		return nil, false, nil
+	case "/quit":
+		return nil, true, nil
+	case "/thread":
+		if arg == "" {
+			return nil, false, errors.New("usage: /thread <id>")
+		}
+		newTh, err := store.Load(arg)
+		if err != nil {
+			return nil, false, err
+		}
+		*threadID = arg
+		return newTh, false, nil
+	case "/provider":
+		if arg == "" {
+			return nil, false, errors.New("usage: /provider <name>")
+		}
+		*providerName = arg
+		fmt.Printf("provider set to %s\n", *providerName)
+		return nil, false, nil
+	case "/model":
+		if arg == "" {
+			return nil, false, errors.New("usage: /model <name>")
+		}
+		*model = arg
+		fmt.Printf("model set to %s\n", *model)
+		return nil, false, nil
+	case "/max-tokens":
+		n, err := strconv.Atoi(arg)
+		if err != nil {
+			return nil, false, fmt.Errorf("usage: /max-tokens <N>: %w", err)
+		}
+		*maxToks = n
+		return nil, false, nil
+	case "/save":
+		if err := store.Save(th); err != nil {
+			return nil, false, fmt.Errorf("save thread: %w", err)
+		}
+		fmt.Println("thread saved")
+		return nil, false, nil
+	case "/reset":
+		return &Thread{}, false, nil
+	case "/cite":
+		*showCitations = !*showCitations
+		fmt.Printf("citations: %v\n", *showCitations)
+		return nil, false, nil
+	default:
+		return nil, false, fmt.Errorf("unknown command: %s", cmd)
+	}
+}
+
+func handleThreadLogic(
+	store ThreadStore,
+	idPrefix, userQuery string,
+) (*Thread, error) {
+	if idPrefix != "" {
+		th, err := store.Load(idPrefix)
+		if err != nil {
+			return nil, err
+		}
+		th.Messages = append(th.Messages, Message{Role: "user", Content: userQuery})
+		return th, nil
 	}
 	th := &Thread{Messages: []Message{{Role: "user", Content: userQuery}}}
 	th.ID = newThreadID(th.Messages)
@@ -346,169 +1365,626 @@ func closeBody(resp *http.Response) {
 	}
 }
 
-// readSSE has been refactored to keep complexity <= 10
-func readSSE(reader *sse.EventStreamReader) (string, []string, error) {
+// retryOptions bounds a streamCompletion call: Timeout caps each individual
+// attempt, Retries caps how many times a transient failure is retried. The
+// overall wall-clock deadline, if any, is applied by the caller to ctx via
+// withOverallDeadline so it bounds the whole retry loop, not one attempt.
+type retryOptions struct {
+	Timeout time.Duration
+	Retries int
+}
+
+// withOverallDeadline wraps ctx with an absolute deadline computed from now,
+// if deadline is set, so --deadline bounds an entire streamCompletion call
+// (including retries) rather than any single attempt.
+func withOverallDeadline(ctx context.Context, deadline time.Duration) (context.Context, context.CancelFunc) {
+	if deadline <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithDeadline(ctx, time.Now().Add(deadline))
+}
+
+// withAttemptTimeout wraps ctx with a per-attempt timeout, if set.
+func withAttemptTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// retryBackoff computes exponential backoff with jitter for the given
+// 1-indexed attempt number, capped at retryBackoffMax.
+func retryBackoff(attempt int) time.Duration {
+	d := retryBackoffBase * time.Duration(int64(1)<<uint(attempt-1))
+	if d > retryBackoffMax || d <= 0 {
+		d = retryBackoffMax
+	}
+	jitter := time.Duration(mrand.Int63n(int64(d)/2 + 1))
+	return d/2 + jitter
+}
+
+// streamCompletion is the shared streaming core used by both the one-shot
+// `get` command and the `chat` REPL: it asks the provider for a Delta
+// channel, renders it via render, and persists the result.
+//
+// On a transient failure (truncated SSE, transport read error) it retries
+// with exponential backoff, resuming by appending the partial assistant
+// output seen so far as context so the provider continues the turn rather
+// than starting over. The partial placeholder is stripped from the thread
+// once a final (successful or exhausted) result is saved.
+func streamCompletion(
+	ctx context.Context,
+	provider Provider,
+	model string,
+	th *Thread,
+	store ThreadStore,
+	maxTokens int,
+	showCitations bool,
+	retry retryOptions,
+	render outputRenderer,
+) error {
+	model = provider.ResolveModel(model)
+
+	var accumulated strings.Builder
+	var citations []string
+	partialAppended := 0
+
+	for attempt := 1; ; attempt++ {
+		attemptStart := time.Now()
+		attemptCtx, cancel := withAttemptTimeout(ctx, retry.Timeout)
+		deltaCh, err := provider.StreamCompletion(attemptCtx, th, CompletionOptions{
+			Model:     model,
+			MaxTokens: maxTokens,
+		})
+		if err != nil {
+			cancel()
+			th.Messages = th.Messages[:len(th.Messages)-partialAppended]
+			return err
+		}
+
+		content, cites, streamErr := render.consume(attemptCtx, deltaCh)
+		cancel()
+		if debug {
+			fmt.Fprintf(os.Stderr, "DEBUG: attempt %d took %s: err=%v\n",
+				attempt, time.Since(attemptStart), streamErr)
+		}
+		accumulated.WriteString(content)
+		if len(cites) > 0 {
+			citations = cites
+		}
+
+		if streamErr == nil {
+			th.Messages = th.Messages[:len(th.Messages)-partialAppended]
+			if err := handleCompletionResponse(
+				store, th, accumulated.String(), citations, showCitations, provider.Name(), model, false,
+			); err != nil {
+				return err
+			}
+			return render.finish(th, accumulated.String(), citations)
+		}
+
+		if !isTransient(streamErr) || attempt > retry.Retries {
+			th.Messages = th.Messages[:len(th.Messages)-partialAppended]
+			if accumulated.Len() > 0 {
+				if serr := handleCompletionResponse(
+					store, th, accumulated.String(), citations, showCitations, provider.Name(), model, true,
+				); serr != nil {
+					return serr
+				}
+			}
+			return fmt.Errorf("stream completion: %w", streamErr)
+		}
+
+		if content != "" {
+			th.Messages = append(th.Messages, Message{
+				Role: "assistant", Content: content,
+				Provider: provider.Name(), Model: model, Partial: true,
+			})
+			partialAppended++
+		}
+
+		backoff := retryBackoff(attempt)
+		if debug {
+			fmt.Fprintf(os.Stderr, "DEBUG: transient error, retrying in %s (attempt %d/%d): %v\n",
+				backoff, attempt, retry.Retries, streamErr)
+		}
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// consumeDeltas renders a Delta channel to the terminal via the smooth
+// printer and assembles the final content and citations, the same way
+// readSSE used to do directly against a raw SSE reader.
+func consumeDeltas(ctx context.Context, deltaCh <-chan Delta) (string, []string, error) {
 	fmt.Print(stopCursorCode)
 	defer fmt.Print(startCursorCode)
 
 	var final strings.Builder
-	var buf bytes.Buffer
 	var finalCitations []string
 
-	ctx, cancel := context.WithCancel(context.Background())
+	printCtx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
 	var wg sync.WaitGroup
 	charCh := make(chan rune, smoothPrintBufferSize)
-	startSmoothPrinter(ctx, &wg, charCh)
+	startSmoothPrinter(printCtx, &wg, charCh)
 
-	for {
-		raw, err := reader.ReadEvent()
-		done, checkErr := checkEventEnd(raw, err)
-		if checkErr != nil {
+	for d := range deltaCh {
+		if d.Err != nil {
 			close(charCh)
 			wg.Wait()
-			return "", nil, checkErr
+			return final.String(), finalCitations, d.Err
 		}
-		if done {
-			close(charCh)
-			wg.Wait()
-			return final.String(), finalCitations, nil
+		if len(d.Citations) > 0 {
+			finalCitations = d.Citations
 		}
-		if err := processSSEChunk(ctx, raw, &buf, &final, &finalCitations, charCh); err != nil {
-			close(charCh)
-			wg.Wait()
-			return "", nil, err
+		final.WriteString(d.Content)
+		for _, r := range d.Content {
+			select {
+			case charCh <- r:
+			case <-printCtx.Done():
+				close(charCh)
+				wg.Wait()
+				return final.String(), finalCitations, printCtx.Err()
+			}
 		}
 	}
+	close(charCh)
+	wg.Wait()
+	return final.String(), finalCitations, nil
 }
 
-// processSSEChunk in a separate function to reduce cyclomatic complexity.
-func processSSEChunk(
-	ctx context.Context,
-	raw []byte,
-	buf *bytes.Buffer,
-	final *strings.Builder,
-	finalCitations *[]string,
-	charCh chan rune,
-) error {
-	if len(raw) == 0 {
-		return nil
+// outputRenderer controls how a `get` completion is shown to the user.
+// Some formats (text, ndjson) render deltas live as they stream; others
+// (json, md, template) silently drain the stream and print the whole
+// result once in finish, after the turn has been saved.
+type outputRenderer interface {
+	// consume drains deltaCh. On error it still returns the content and
+	// citations received before the error, which streamCompletion keeps as
+	// partial output.
+	consume(ctx context.Context, deltaCh <-chan Delta) (string, []string, error)
+	// finish renders the final result after the turn has been saved.
+	// content is the assistant text accumulated across every attempt, i.e.
+	// what consume returned, preceded by any partial output from earlier
+	// attempts that were retried; it may be
+	// empty if the provider's stream ended without producing any content,
+	// in which case handleCompletionResponse appended no assistant message
+	// to th, so implementations must not assume th's last message is it.
+	finish(th *Thread, content string, citations []string) error
+}
+
+// textOutputRenderer is the original `get`/`chat` behavior: smooth-print
+// deltas to the terminal as they arrive, with citations (if any) printed
+// as a plain list by handleCompletionResponse.
+type textOutputRenderer struct{}
+
+func (textOutputRenderer) consume(ctx context.Context, deltaCh <-chan Delta) (string, []string, error) {
+	return consumeDeltas(ctx, deltaCh)
+}
+
+func (textOutputRenderer) finish(*Thread, string, []string) error { return nil }
+
+// ndjsonEvent is the schema ndjsonOutputRenderer emits: one line per SSE
+// delta, one per citation batch, and a closing "done" line once the
+// provider's stream ends, so downstream pipelines can consume plexctl's
+// output programmatically without scraping terminal text.
+type ndjsonEvent struct {
+	Type      string   `json:"type"`
+	Content   string   `json:"content,omitempty"`
+	Citations []string `json:"citations,omitempty"`
+	ThreadID  string   `json:"thread_id,omitempty"`
+}
+
+type ndjsonOutputRenderer struct{}
+
+func (ndjsonOutputRenderer) consume(_ context.Context, deltaCh <-chan Delta) (string, []string, error) {
+	enc := json.NewEncoder(os.Stdout)
+	var final strings.Builder
+	var citations []string
+	for d := range deltaCh {
+		if d.Err != nil {
+			return final.String(), citations, d.Err
+		}
+		if d.Content != "" {
+			final.WriteString(d.Content)
+			if err := enc.Encode(ndjsonEvent{Type: "delta", Content: d.Content}); err != nil {
+				return "", nil, err
+			}
+		}
+		if len(d.Citations) > 0 {
+			citations = d.Citations
+			if err := enc.Encode(ndjsonEvent{Type: "citation", Citations: d.Citations}); err != nil {
+				return "", nil, err
+			}
+		}
 	}
-	dataIdx := bytes.Index(raw, []byte("data: "))
-	appendSSEChunk(buf, raw, dataIdx)
+	return final.String(), citations, nil
+}
 
-	var chunk sseChunk
-	if err := json.Unmarshal(buf.Bytes(), &chunk); err != nil {
-		// nolint:nilerr
-		return nil
+func (ndjsonOutputRenderer) finish(th *Thread, _ string, _ []string) error {
+	return json.NewEncoder(os.Stdout).Encode(ndjsonEvent{Type: "done", ThreadID: th.ID})
+}
+
+// silentOutputRenderer backs the json, md, and template formats: it drains
+// deltaCh without printing anything live, then renders the complete result
+// in finish, once handleCompletionResponse has saved the turn.
+type silentOutputRenderer struct {
+	format outputFormat
+	tmpl   *template.Template
+}
+
+func (r silentOutputRenderer) consume(_ context.Context, deltaCh <-chan Delta) (string, []string, error) {
+	var final strings.Builder
+	var citations []string
+	for d := range deltaCh {
+		if d.Err != nil {
+			return final.String(), citations, d.Err
+		}
+		final.WriteString(d.Content)
+		if len(d.Citations) > 0 {
+			citations = d.Citations
+		}
 	}
-	buf.Reset()
+	return final.String(), citations, nil
+}
 
-	if len(chunk.Citations) > 0 {
-		*finalCitations = chunk.Citations
+func (r silentOutputRenderer) finish(th *Thread, content string, citations []string) error {
+	// handleCompletionResponse only appends an assistant message when
+	// content is non-empty, so th's last message is only guaranteed to be
+	// it in that case; otherwise leave provider/model blank rather than
+	// misreporting the preceding user message as the response.
+	var providerName, modelName string
+	if content != "" {
+		last := th.Messages[len(th.Messages)-1]
+		providerName, modelName = last.Provider, last.Model
 	}
-	if len(chunk.Choices) == 0 {
+	switch r.format {
+	case outputJSON:
+		return json.NewEncoder(os.Stdout).Encode(struct {
+			ThreadID  string   `json:"thread_id"`
+			Content   string   `json:"content"`
+			Citations []string `json:"citations,omitempty"`
+			Provider  string   `json:"provider"`
+			Model     string   `json:"model"`
+		}{th.ID, content, citations, providerName, modelName})
+	case outputMarkdown:
+		fmt.Println(content)
+		if len(citations) > 0 {
+			fmt.Println()
+			for i, c := range citations {
+				fmt.Printf("[%d]: %s\n", i+1, c)
+			}
+		}
 		return nil
+	case outputTemplate:
+		return r.tmpl.Execute(os.Stdout, struct {
+			Thread    *Thread
+			Content   string
+			Citations []string
+		}{th, content, citations})
+	default:
+		return fmt.Errorf("unsupported renderer format %q", r.format)
+	}
+}
+
+// newOutputRenderer builds the outputRenderer for a `get` invocation's
+// --output/--template flags.
+func newOutputRenderer(format outputFormat, tmplText string) (outputRenderer, error) {
+	switch format {
+	case outputText:
+		return textOutputRenderer{}, nil
+	case outputNDJSON:
+		return ndjsonOutputRenderer{}, nil
+	case outputJSON, outputMarkdown:
+		return silentOutputRenderer{format: format}, nil
+	case outputTemplate:
+		if tmplText == "" {
+			return nil, errors.New("--output template requires --template")
+		}
+		tmpl, err := template.New("plexctl").Parse(tmplText)
+		if err != nil {
+			return nil, fmt.Errorf("parse --template: %w", err)
+		}
+		return silentOutputRenderer{format: format, tmpl: tmpl}, nil
+	default:
+		return nil, fmt.Errorf("unknown --output format %q", format)
 	}
-	txt := chunk.Choices[0].Delta.Content
-	final.WriteString(txt)
+}
 
-	for _, r := range txt {
+// newInterruptibleContext returns a context cancelled the first time the
+// process receives SIGINT, so a Ctrl-C cancels only the in-flight
+// completion rather than the whole process.
+func newInterruptibleContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt)
+	go func() {
 		select {
-		case charCh <- r:
+		case <-sigCh:
+			cancel()
 		case <-ctx.Done():
-			return ctx.Err()
+		}
+		signal.Stop(sigCh)
+	}()
+	return ctx, cancel
+}
+
+// handleCompletionResponse is factored out to help keep streamCompletion short.
+// partial marks the saved assistant message as incomplete (retries exhausted
+// or the query was cancelled mid-stream) so `thread get` can annotate it.
+func handleCompletionResponse(
+	store ThreadStore,
+	th *Thread,
+	finalContent string,
+	citations []string,
+	showCitations bool,
+	providerName, model string,
+	partial bool,
+) error {
+	if finalContent != "" {
+		th.Messages = append(th.Messages, Message{
+			Role:      "assistant",
+			Content:   finalContent,
+			Provider:  providerName,
+			Model:     model,
+			Partial:   partial,
+			Citations: citations,
+		})
+		if err := store.Save(th); err != nil {
+			return fmt.Errorf("save thread: %w", err)
+		}
+	}
+	if showCitations && len(citations) > 0 {
+		fmt.Println("\n\nCitations:")
+		for i, c := range citations {
+			fmt.Printf("[%d] %s\n", i+1, c)
 		}
 	}
 	return nil
 }
 
-func streamCompletion(
-	token, model string,
-	th *Thread,
+// queryResult summarizes one query's outcome in a fan-out run.
+type queryResult struct {
+	query    string
+	threadID string
+	status   string
+	tokens   int
+	elapsed  time.Duration
+	err      error
+}
+
+// fanOutJob tracks the live progress of one in-flight fan-out query.
+type fanOutJob struct {
+	mu     sync.Mutex
+	query  string
+	tokens int
+	status string
+	start  time.Time
+}
+
+// fanOutProgress is the shared, mutex-guarded state the progress renderer
+// reads and the query workers write to.
+type fanOutProgress struct {
+	jobs []*fanOutJob
+}
+
+func newFanOutProgress(queries []string) *fanOutProgress {
+	jobs := make([]*fanOutJob, len(queries))
+	for i, q := range queries {
+		jobs[i] = &fanOutJob{query: q, status: "queued", start: time.Now()}
+	}
+	return &fanOutProgress{jobs: jobs}
+}
+
+func (p *fanOutProgress) setStatus(idx int, status string) {
+	p.jobs[idx].mu.Lock()
+	p.jobs[idx].status = status
+	p.jobs[idx].mu.Unlock()
+}
+
+func (p *fanOutProgress) addTokens(idx, n int) {
+	p.jobs[idx].mu.Lock()
+	p.jobs[idx].tokens += n
+	p.jobs[idx].mu.Unlock()
+}
+
+func (p *fanOutProgress) tokensFor(idx int) int {
+	p.jobs[idx].mu.Lock()
+	defer p.jobs[idx].mu.Unlock()
+	return p.jobs[idx].tokens
+}
+
+func (p *fanOutProgress) render(w io.Writer) {
+	for _, j := range p.jobs {
+		j.mu.Lock()
+		fmt.Fprintf(w, "[%-9s] %6d tok  %6s  %s\n",
+			j.status, j.tokens, time.Since(j.start).Round(time.Second), snippet(j.query))
+		j.mu.Unlock()
+	}
+}
+
+// startFanOutRenderer redraws a multi-line progress display in place,
+// one row per in-flight query, using the same cursor-control escapes as
+// the single-stream smooth printer. It stops after one final render once
+// ctx is cancelled.
+func startFanOutRenderer(ctx context.Context, wg *sync.WaitGroup, progress *fanOutProgress) {
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		n := len(progress.jobs)
+		fmt.Print(stopCursorCode)
+		defer fmt.Print(startCursorCode)
+
+		tick := time.NewTicker(fanOutRedrawInterval)
+		defer tick.Stop()
+		drawn := false
+		for {
+			if drawn {
+				fmt.Printf("\x1b[%dA", n)
+			}
+			drawn = true
+			progress.render(os.Stdout)
+			select {
+			case <-ctx.Done():
+				fmt.Printf("\x1b[%dA", n)
+				progress.render(os.Stdout)
+				return
+			case <-tick.C:
+			}
+		}
+	}()
+}
+
+// fanOutOutputRenderer adapts a fan-out worker to the outputRenderer
+// interface so runFanOutQuery can share streamCompletion's retry/backoff
+// loop with the single-query `get` path, instead of streaming providers
+// directly: it drains deltaCh silently (the shared fanOutProgress redraw
+// loop handles live display) while recording token counts as they arrive.
+type fanOutOutputRenderer struct {
+	progress *fanOutProgress
+	idx      int
+}
+
+func (r fanOutOutputRenderer) consume(_ context.Context, deltaCh <-chan Delta) (string, []string, error) {
+	var final strings.Builder
+	var citations []string
+	for d := range deltaCh {
+		if d.Err != nil {
+			return final.String(), citations, d.Err
+		}
+		final.WriteString(d.Content)
+		r.progress.addTokens(r.idx, utf8.RuneCountInString(d.Content))
+		if len(d.Citations) > 0 {
+			citations = d.Citations
+		}
+	}
+	return final.String(), citations, nil
+}
+
+func (fanOutOutputRenderer) finish(*Thread, string, []string) error { return nil }
+
+// runFanOut drives the concurrent multi-query path for `get`: a bounded
+// worker pool streams each query independently through the same
+// streamCompletion retry/backoff loop as the single-query path, a redraw
+// loop renders live progress, and SIGINT (via newInterruptibleContext)
+// cancels every in-flight query so partial output still gets saved to disk.
+func runFanOut(
+	token, providerName, model string,
+	queries []string,
+	maxTokens, concurrency int,
 	store ThreadStore,
-	maxTokens int,
+	retry *retryFlags,
 ) error {
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
-	resp, err := doCompletionRequest(ctx, token, model, th, maxTokens)
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	provider, err := newProvider(providerName, token)
 	if err != nil {
 		return err
 	}
-	defer closeBody(resp)
 
-	reader := sse.NewEventStreamReader(resp.Body, maxSSEBytes)
-	finalContent, citations, sseErr := readSSE(reader)
-	if sseErr != nil {
-		return fmt.Errorf("read SSE: %w", sseErr)
+	ctx, cancel := newInterruptibleContext()
+	defer cancel()
+
+	progress := newFanOutProgress(queries)
+	var renderWG sync.WaitGroup
+	startFanOutRenderer(ctx, &renderWG, progress)
+
+	results := make([]queryResult, len(queries))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, q := range queries {
+		wg.Add(1)
+		go func(i int, q string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			results[i] = runFanOutQuery(ctx, i, q, provider, model, maxTokens, store, progress, retry)
+		}(i, q)
 	}
-	return handleCompletionResponse(store, th, finalContent, citations)
+	wg.Wait()
+	cancel()
+	renderWG.Wait()
+
+	return printFanOutSummary(results)
 }
 
-// doCompletionRequest is factored out to help shorten streamCompletion.
-func doCompletionRequest(
+// runFanOutQuery runs a single query to completion (or cancellation) via
+// streamCompletion, so --timeout/--deadline/--retries apply to fan-out
+// queries exactly as they do to the single-query path, with token counts
+// reported to progress as deltas arrive via fanOutOutputRenderer.
+func runFanOutQuery(
 	ctx context.Context,
-	token, model string,
-	th *Thread,
+	idx int,
+	query string,
+	provider Provider,
+	model string,
 	maxTokens int,
-) (*http.Response, error) {
-	reqBody := ChatCompletionRequest{
-		Model:    model,
-		Messages: th.Messages,
-		Stream:   true,
-	}
-	if maxTokens > 0 {
-		reqBody.MaxTokens = &maxTokens
-	}
-	bodyJSON, _ := json.Marshal(reqBody)
+	store ThreadStore,
+	progress *fanOutProgress,
+	retry *retryFlags,
+) queryResult {
+	start := time.Now()
+	progress.setStatus(idx, "running")
 
-	req, err := http.NewRequestWithContext(
-		ctx,
-		http.MethodPost,
-		"https://api.perplexity.ai/chat/completions",
-		bytes.NewReader(bodyJSON),
-	)
+	th, err := handleThreadLogic(store, "", query)
 	if err != nil {
-		return nil, fmt.Errorf("request creation: %w", err)
+		progress.setStatus(idx, "error")
+		return queryResult{query: query, status: "error", err: err, elapsed: time.Since(start)}
 	}
-	req.Header.Set("Authorization", "Bearer "+token)
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Accept", "text/event-stream")
 
-	resp, err := http.DefaultClient.Do(req)
+	queryCtx, cancel := withOverallDeadline(ctx, retry.Deadline)
+	defer cancel()
+
+	err = streamCompletion(
+		queryCtx, provider, model, th, store, maxTokens, false, retry.toOptions(),
+		fanOutOutputRenderer{progress: progress, idx: idx},
+	)
+	status := "ok"
 	if err != nil {
-		return nil, fmt.Errorf("request execute: %w", err)
+		status = "error"
+		if errors.Is(err, context.Canceled) {
+			status = "cancelled"
+		}
 	}
-	if resp.StatusCode != http.StatusOK {
-		closeBody(resp)
-		return nil, fmt.Errorf("bad status: %d", resp.StatusCode)
+
+	progress.setStatus(idx, status)
+	return queryResult{
+		query:    query,
+		threadID: th.ID,
+		status:   status,
+		tokens:   progress.tokensFor(idx),
+		elapsed:  time.Since(start),
+		err:      err,
 	}
-	return resp, nil
 }
 
-// handleCompletionResponse is factored out to help keep streamCompletion short.
-func handleCompletionResponse(
-	store ThreadStore,
-	th *Thread,
-	finalContent string,
-	citations []string,
-) error {
-	if finalContent != "" {
-		th.Messages = append(th.Messages,
-			Message{Role: "assistant", Content: finalContent})
-		if err := store.Save(th); err != nil {
-			return fmt.Errorf("save thread: %w", err)
-		}
+// printFanOutSummary renders the per-query outcome table once a fan-out
+// run finishes, in the same tabwriter style as `thread`.
+func printFanOutSummary(results []queryResult) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, tabwriterPadding, ' ', 0)
+	if _, err := fmt.Fprintln(w, "STATUS\tTOKENS\tELAPSED\tTHREAD ID\tQUERY"); err != nil {
+		return err
 	}
-	if len(citations) > 0 {
-		fmt.Println("\n\nCitations:")
-		for i, c := range citations {
-			fmt.Printf("[%d] %s\n", i+1, c)
+	for _, r := range results {
+		id := r.threadID
+		if len(id) > idTruncLen {
+			id = id[:idTruncLen]
+		}
+		status := r.status
+		if r.err != nil {
+			status = fmt.Sprintf("%s (%v)", status, r.err)
+		}
+		if _, err := fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\n",
+			status, r.tokens, r.elapsed.Round(time.Second), id, snippet(r.query)); err != nil {
+			return err
 		}
 	}
-	return nil
+	return w.Flush()
 }
 
 // startSmoothPrinter prints runes at intervals in a goroutine.
@@ -537,18 +2013,25 @@ func startSmoothPrinter(
 	}()
 }
 
-func checkEventEnd(raw []byte, err error) (bool, error) {
+// checkEventEnd reports whether the SSE stream has ended, sending a Delta
+// on ch when it has. An EOF before the `[DONE]` sentinel is a truncated
+// stream rather than a clean finish, so it's surfaced as a transientError
+// the retry loop in streamCompletion can act on.
+func checkEventEnd(ctx context.Context, ch chan<- Delta, raw []byte, err error, sawDone *bool) bool {
 	if err != nil && errors.Is(err, io.EOF) {
 		if debug {
-			fmt.Fprintln(
-				os.Stderr,
-				"DEBUG: Received EOF from server.",
-			)
+			fmt.Fprintln(os.Stderr, "DEBUG: Received EOF from server.")
 		}
-		return true, nil
+		if !*sawDone {
+			sendDelta(ctx, ch, Delta{
+				Err: &transientError{errors.New("truncated SSE stream: EOF before [DONE]")},
+			})
+		}
+		return true
 	}
 	if err != nil {
-		return true, fmt.Errorf("SSE read: %w", err)
+		sendDelta(ctx, ch, Delta{Err: &transientError{fmt.Errorf("SSE read: %w", err)}})
+		return true
 	}
 	if debug && len(raw) > 0 {
 		fmt.Fprintf(os.Stderr, "\nDEBUG: Raw SSE event: %q\n", raw)
@@ -557,9 +2040,10 @@ func checkEventEnd(raw []byte, err error) (bool, error) {
 		if debug {
 			fmt.Fprintln(os.Stderr, "DEBUG: Got [DONE] sentinel.")
 		}
-		return true, nil
+		*sawDone = true
+		return true
 	}
-	return false, nil
+	return false
 }
 
 func appendSSEChunk(buf *bytes.Buffer, raw []byte, dataIdx int) {
@@ -570,8 +2054,6 @@ func appendSSEChunk(buf *bytes.Buffer, raw []byte, dataIdx int) {
 	buf.Write(raw[dataIdx+ssePrefixDataSize:])
 }
 
-// parsePartialSSE was unused, so we remove it to satisfy lint (unused).
-
 func snippet(s string) string {
 	if len(s) > snippetLen {
 		return s[:snippetLen] + "..."
@@ -579,17 +2061,451 @@ func snippet(s string) string {
 	return s
 }
 
+// --- Perplexity provider ---
+//
+// Perplexity speaks the OpenAI chat-completions wire format: an SSE stream
+// of `data: {...}` chunks terminated by a `[DONE]` sentinel.
+
+type perplexityRequest struct {
+	Model     string    `json:"model"`
+	Messages  []Message `json:"messages"`
+	Stream    bool      `json:"stream"`
+	MaxTokens *int      `json:"max_tokens,omitempty"`
+}
+
+type perplexitySSEChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+	} `json:"choices"`
+	Citations []string `json:"citations"`
+}
+
+type PerplexityProvider struct {
+	cfg providerConfig
+}
+
+func (p *PerplexityProvider) Name() string { return perplexityProviderName }
+
+func (p *PerplexityProvider) ResolveModel(model string) string {
+	if model == "" {
+		return p.cfg.model
+	}
+	return model
+}
+
+func (p *PerplexityProvider) StreamCompletion(
+	ctx context.Context,
+	th *Thread,
+	opts CompletionOptions,
+) (<-chan Delta, error) {
+	model := p.ResolveModel(opts.Model)
+	reqBody := perplexityRequest{Model: model, Messages: th.Messages, Stream: true}
+	if opts.MaxTokens > 0 {
+		reqBody.MaxTokens = &opts.MaxTokens
+	}
+	resp, err := doJSONStreamRequest(ctx, p.cfg, reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan Delta)
+	go streamOpenAIStyleSSE(ctx, resp, unmarshalPerplexityChunk, ch)
+	return ch, nil
+}
+
+func unmarshalPerplexityChunk(data []byte) (string, []string, error) {
+	var chunk perplexitySSEChunk
+	if err := json.Unmarshal(data, &chunk); err != nil {
+		return "", nil, err
+	}
+	if len(chunk.Choices) == 0 {
+		return "", chunk.Citations, nil
+	}
+	return chunk.Choices[0].Delta.Content, chunk.Citations, nil
+}
+
+// --- OpenAI provider ---
+//
+// Structurally identical to Perplexity's wire format, but kept as its own
+// types/provider so each backend's schema can drift independently.
+
+type openAIRequest struct {
+	Model     string    `json:"model"`
+	Messages  []Message `json:"messages"`
+	Stream    bool      `json:"stream"`
+	MaxTokens *int      `json:"max_tokens,omitempty"`
+}
+
+type openAISSEChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+	} `json:"choices"`
+}
+
+type OpenAIProvider struct {
+	cfg providerConfig
+}
+
+func (p *OpenAIProvider) Name() string { return openAIProviderName }
+
+func (p *OpenAIProvider) ResolveModel(model string) string {
+	if model == "" {
+		return p.cfg.model
+	}
+	return model
+}
+
+func (p *OpenAIProvider) StreamCompletion(
+	ctx context.Context,
+	th *Thread,
+	opts CompletionOptions,
+) (<-chan Delta, error) {
+	model := p.ResolveModel(opts.Model)
+	reqBody := openAIRequest{Model: model, Messages: th.Messages, Stream: true}
+	if opts.MaxTokens > 0 {
+		reqBody.MaxTokens = &opts.MaxTokens
+	}
+	resp, err := doJSONStreamRequest(ctx, p.cfg, reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan Delta)
+	go streamOpenAIStyleSSE(ctx, resp, unmarshalOpenAIChunk, ch)
+	return ch, nil
+}
+
+func unmarshalOpenAIChunk(data []byte) (string, []string, error) {
+	var chunk openAISSEChunk
+	if err := json.Unmarshal(data, &chunk); err != nil {
+		return "", nil, err
+	}
+	if len(chunk.Choices) == 0 {
+		return "", nil, nil
+	}
+	return chunk.Choices[0].Delta.Content, nil, nil
+}
+
+// streamOpenAIStyleSSE parses an OpenAI-wire-format SSE stream (shared by
+// the Perplexity and OpenAI providers, which only differ in chunk schema)
+// and pushes Deltas onto ch until `[DONE]` or an error is seen.
+func streamOpenAIStyleSSE(
+	ctx context.Context,
+	resp *http.Response,
+	unmarshalChunk func([]byte) (content string, citations []string, err error),
+	ch chan<- Delta,
+) {
+	defer close(ch)
+	defer closeBody(resp)
+
+	reader := sse.NewEventStreamReader(resp.Body, maxSSEBytes)
+	var buf bytes.Buffer
+	sawDone := false
+	for {
+		raw, err := reader.ReadEvent()
+		if checkEventEnd(ctx, ch, raw, err, &sawDone) {
+			return
+		}
+		if len(raw) == 0 {
+			continue
+		}
+		dataIdx := bytes.Index(raw, []byte("data: "))
+		appendSSEChunk(&buf, raw, dataIdx)
+		content, citations, perr := unmarshalChunk(buf.Bytes())
+		buf.Reset()
+		if perr != nil {
+			// nolint:nilerr
+			continue
+		}
+		if content == "" && len(citations) == 0 {
+			continue
+		}
+		if !sendDelta(ctx, ch, Delta{Content: content, Citations: citations}) {
+			return
+		}
+	}
+}
+
+// sendDelta pushes d onto ch, returning false if ctx was cancelled first.
+func sendDelta(ctx context.Context, ch chan<- Delta, d Delta) bool {
+	select {
+	case ch <- d:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// --- Anthropic provider ---
+//
+// Anthropic's Messages API uses a distinct event-stream schema: typed
+// events (`content_block_delta`, `message_stop`, ...) rather than an
+// OpenAI-style `choices[].delta.content` payload with a `[DONE]` sentinel.
+
+type anthropicRequest struct {
+	Model     string             `json:"model"`
+	Messages  []anthropicMessage `json:"messages"`
+	Stream    bool               `json:"stream"`
+	MaxTokens int                `json:"max_tokens"`
+}
+
+type anthropicMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type anthropicSSEEvent struct {
+	Type  string `json:"type"`
+	Delta struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"delta"`
+}
+
+const anthropicDefaultMaxTokens = 1024
+
+type AnthropicProvider struct {
+	cfg providerConfig
+}
+
+func (p *AnthropicProvider) Name() string { return anthropicProviderName }
+
+func (p *AnthropicProvider) ResolveModel(model string) string {
+	if model == "" {
+		return p.cfg.model
+	}
+	return model
+}
+
+func (p *AnthropicProvider) StreamCompletion(
+	ctx context.Context,
+	th *Thread,
+	opts CompletionOptions,
+) (<-chan Delta, error) {
+	model := p.ResolveModel(opts.Model)
+	maxTokens := opts.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = anthropicDefaultMaxTokens
+	}
+	messages := make([]anthropicMessage, len(th.Messages))
+	for i, m := range th.Messages {
+		messages[i] = anthropicMessage{Role: m.Role, Content: m.Content}
+	}
+	reqBody := anthropicRequest{
+		Model:     model,
+		Messages:  messages,
+		Stream:    true,
+		MaxTokens: maxTokens,
+	}
+	bodyJSON, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("marshal request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(
+		ctx, http.MethodPost, p.cfg.endpoint, bytes.NewReader(bodyJSON),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("request creation: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("anthropic-version", anthropicAPIVersion)
+	setAuthHeader(req, p.cfg.authStyle, p.cfg.token)
+
+	resp, err := doAndCheckStatus(req)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan Delta)
+	go streamAnthropicSSE(ctx, resp, ch)
+	return ch, nil
+}
+
+func streamAnthropicSSE(ctx context.Context, resp *http.Response, ch chan<- Delta) {
+	defer close(ch)
+	defer closeBody(resp)
+
+	reader := sse.NewEventStreamReader(resp.Body, maxSSEBytes)
+	var buf bytes.Buffer
+	sawMessageStop := false
+	for {
+		raw, err := reader.ReadEvent()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				if !sawMessageStop {
+					sendDelta(ctx, ch, Delta{
+						Err: &transientError{errors.New("truncated SSE stream: EOF before message_stop")},
+					})
+				}
+				return
+			}
+			sendDelta(ctx, ch, Delta{Err: &transientError{fmt.Errorf("SSE read: %w", err)}})
+			return
+		}
+		if len(raw) == 0 {
+			continue
+		}
+		dataIdx := bytes.Index(raw, []byte("data: "))
+		appendSSEChunk(&buf, raw, dataIdx)
+		var event anthropicSSEEvent
+		perr := json.Unmarshal(buf.Bytes(), &event)
+		buf.Reset()
+		if perr != nil {
+			// nolint:nilerr
+			continue
+		}
+		if event.Type == "message_stop" {
+			sawMessageStop = true
+			return
+		}
+		if event.Type != "content_block_delta" || event.Delta.Text == "" {
+			continue
+		}
+		if !sendDelta(ctx, ch, Delta{Content: event.Delta.Text}) {
+			return
+		}
+	}
+}
+
+// --- Ollama provider ---
+//
+// Ollama's /api/chat endpoint streams newline-delimited JSON objects
+// rather than SSE, so it gets its own reader loop entirely.
+
+type ollamaRequest struct {
+	Model    string    `json:"model"`
+	Messages []Message `json:"messages"`
+	Stream   bool      `json:"stream"`
+}
+
+type ollamaChunk struct {
+	Message struct {
+		Content string `json:"content"`
+	} `json:"message"`
+	Done bool `json:"done"`
+}
+
+type OllamaProvider struct {
+	cfg providerConfig
+}
+
+func (p *OllamaProvider) Name() string { return ollamaProviderName }
+
+func (p *OllamaProvider) ResolveModel(model string) string {
+	if model == "" {
+		return p.cfg.model
+	}
+	return model
+}
+
+func (p *OllamaProvider) StreamCompletion(
+	ctx context.Context,
+	th *Thread,
+	opts CompletionOptions,
+) (<-chan Delta, error) {
+	model := p.ResolveModel(opts.Model)
+	reqBody := ollamaRequest{Model: model, Messages: th.Messages, Stream: true}
+	bodyJSON, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("marshal request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(
+		ctx, http.MethodPost, p.cfg.endpoint, bytes.NewReader(bodyJSON),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("request creation: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := doAndCheckStatus(req)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan Delta)
+	go streamOllamaNDJSON(ctx, resp, ch)
+	return ch, nil
+}
+
+func streamOllamaNDJSON(ctx context.Context, resp *http.Response, ch chan<- Delta) {
+	defer close(ch)
+	defer closeBody(resp)
+
+	scanner := bufio.NewScanner(resp.Body)
+	sawDone := false
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var chunk ollamaChunk
+		if err := json.Unmarshal(line, &chunk); err != nil {
+			// nolint:nilerr
+			continue
+		}
+		if chunk.Done {
+			sawDone = true
+			return
+		}
+		if chunk.Message.Content == "" {
+			continue
+		}
+		if !sendDelta(ctx, ch, Delta{Content: chunk.Message.Content}) {
+			return
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		sendDelta(ctx, ch, Delta{Err: &transientError{fmt.Errorf("ndjson read: %w", err)}})
+		return
+	}
+	if !sawDone {
+		sendDelta(ctx, ch, Delta{Err: &transientError{errors.New("truncated stream: EOF before done")}})
+	}
+}
+
+// doJSONStreamRequest builds and executes a Bearer/Authorization-style
+// POST for the OpenAI-shaped providers (Perplexity, OpenAI).
+func doJSONStreamRequest(ctx context.Context, cfg providerConfig, reqBody interface{}) (*http.Response, error) {
+	bodyJSON, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("marshal request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(
+		ctx, http.MethodPost, cfg.endpoint, bytes.NewReader(bodyJSON),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("request creation: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/event-stream")
+	setAuthHeader(req, cfg.authStyle, cfg.token)
+
+	return doAndCheckStatus(req)
+}
+
+func doAndCheckStatus(req *http.Request) (*http.Response, error) {
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request execute: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		closeBody(resp)
+		return nil, fmt.Errorf("bad status: %d", resp.StatusCode)
+	}
+	return resp, nil
+}
+
 func main() {
 	os.Exit(run())
 }
 
 func run() int {
-	store, err := NewFSStore()
-	if err != nil {
-		fmt.Fprintln(os.Stderr, "failed to init store:", err)
-		return 1
-	}
-	rootCmd := buildRootCmd(store)
+	rootCmd := buildRootCmd()
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Fprintln(os.Stderr, "Error:", err)
 		return 1
@@ -597,30 +2513,48 @@ func run() int {
 	return 0
 }
 
-func buildRootCmd(store ThreadStore) *cobra.Command {
-	var token string
+// retryFlags holds the --timeout/--deadline/--retries persistent flag
+// values, threaded into streamCompletion via toOptions and into
+// withOverallDeadline directly.
+type retryFlags struct {
+	Timeout  time.Duration
+	Deadline time.Duration
+	Retries  int
+}
+
+func (r *retryFlags) toOptions() retryOptions {
+	return retryOptions{Timeout: r.Timeout, Retries: r.Retries}
+}
+
+func buildRootCmd() *cobra.Command {
+	var token, providerName, storeName string
+	var retry retryFlags
 	viper.SetEnvPrefix("PERPLEXITY")
 	viper.AutomaticEnv()
 
-	preRun := func(cmd *cobra.Command, args []string) {
-		if token == "" {
-			token = viper.GetString("API_TOKEN")
-			if token == "" {
-				fmt.Fprintln(
-					os.Stderr,
-					"No token provided. Set via --token or PERPLEXITY_API_TOKEN.",
-				)
-				os.Exit(1)
-			}
-		}
+	storeDefault := defaultStoreName
+	if v := os.Getenv(storeEnvVar); v != "" {
+		storeDefault = v
 	}
 
-	rootCmd := &cobra.Command{Use: "plexctl", PersistentPreRun: preRun}
+	rootCmd := &cobra.Command{Use: "plexctl"}
 	rootCmd.PersistentFlags().StringVar(
 		&token,
 		"token",
 		"",
-		"Perplexity API token (env PERPLEXITY_API_TOKEN)",
+		"API token for the selected provider (overrides its config/env var)",
+	)
+	rootCmd.PersistentFlags().StringVar(
+		&providerName,
+		"provider",
+		defaultProviderName,
+		"Backend provider: perplexity, openai, anthropic, or ollama",
+	)
+	rootCmd.PersistentFlags().StringVar(
+		&storeName,
+		"store",
+		storeDefault,
+		"Thread store backend: fs or bolt (env "+storeEnvVar+")",
 	)
 	rootCmd.PersistentFlags().BoolVar(
 		&debug,
@@ -628,7 +2562,26 @@ func buildRootCmd(store ThreadStore) *cobra.Command {
 		false,
 		"Enable debug logs to stderr",
 	)
-	rootCmd.AddCommand(buildGetCmd(store, &token))
-	rootCmd.AddCommand(buildThreadCmd(store))
+	rootCmd.PersistentFlags().DurationVar(
+		&retry.Timeout,
+		"timeout",
+		0,
+		"Per-attempt streaming timeout (0 = no timeout)",
+	)
+	rootCmd.PersistentFlags().DurationVar(
+		&retry.Deadline,
+		"deadline",
+		0,
+		"Overall deadline for a completion, including retries (0 = no deadline)",
+	)
+	rootCmd.PersistentFlags().IntVar(
+		&retry.Retries,
+		"retries",
+		defaultRetries,
+		"Number of retries on transient stream failures (truncated SSE, read errors)",
+	)
+	rootCmd.AddCommand(buildGetCmd(&storeName, &token, &providerName, &retry))
+	rootCmd.AddCommand(buildChatCmd(&storeName, &token, &providerName, &retry))
+	rootCmd.AddCommand(buildThreadCmd(&storeName))
 	return rootCmd
 }
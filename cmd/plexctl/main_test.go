@@ -0,0 +1,235 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// captureStdout redirects os.Stdout for the duration of fn and returns
+// whatever it wrote.
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	orig := os.Stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("os.Pipe: %v", err)
+	}
+	os.Stdout = w
+	defer func() { os.Stdout = orig }()
+
+	fn()
+
+	if err := w.Close(); err != nil {
+		t.Fatalf("close pipe writer: %v", err)
+	}
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("read pipe: %v", err)
+	}
+	return string(out)
+}
+
+func TestShingles(t *testing.T) {
+	cases := []struct {
+		name string
+		in   string
+		want []string
+	}{
+		{"empty", "", nil},
+		{"drops short words", "a is to be or", nil},
+		{"lowercases", "Hello WORLD", []string{"hello", "world"}},
+		{"splits on punctuation", "foo, bar! baz?", []string{"foo", "bar", "baz"}},
+		{"dedups repeats", "perplexity perplexity model", []string{"perplexity", "model"}},
+		{"keeps digits", "gpt4 and claude3", []string{"gpt4", "and", "claude3"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := shingles(tc.in)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("shingles(%q) = %v, want %v", tc.in, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestBoltStoreRoundTrip(t *testing.T) {
+	store, err := openBoltStore(filepath.Join(t.TempDir(), "threads.db"))
+	if err != nil {
+		t.Fatalf("openBoltStore: %v", err)
+	}
+	defer store.Close()
+
+	th1 := &Thread{
+		ID: "thread-one",
+		Messages: []Message{
+			{Role: "user", Content: "tell me about perplexity pricing"},
+			{Role: "assistant", Content: "perplexity offers a pay-as-you-go API"},
+		},
+	}
+	th2 := &Thread{
+		ID: "thread-two",
+		Messages: []Message{
+			{Role: "user", Content: "what model does ollama run locally"},
+		},
+	}
+	for _, th := range []*Thread{th1, th2} {
+		if err := store.Save(th); err != nil {
+			t.Fatalf("Save(%s): %v", th.ID, err)
+		}
+	}
+
+	loaded, err := store.Load("thread-one")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if loaded.ID != th1.ID || len(loaded.Messages) != len(th1.Messages) {
+		t.Errorf("Load returned %+v, want %+v", loaded, th1)
+	}
+
+	all, err := store.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(all) != 2 {
+		t.Errorf("List returned %d threads, want 2", len(all))
+	}
+
+	results, err := store.Search("perplexity")
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(results) != 1 || results[0].ID != th1.ID {
+		t.Errorf("Search(\"perplexity\") = %+v, want only %s", results, th1.ID)
+	}
+
+	if _, err := store.Search("nonexistentquery"); err != nil {
+		t.Errorf("Search with no matches should not error: %v", err)
+	}
+
+	if err := store.Tag("thread-one", "favorites"); err != nil {
+		t.Fatalf("Tag: %v", err)
+	}
+	if err := store.Tag("thread-two", "favorites"); err != nil {
+		t.Fatalf("Tag: %v", err)
+	}
+
+	tagged, err := store.ListByTag("favorites")
+	if err != nil {
+		t.Fatalf("ListByTag: %v", err)
+	}
+	if len(tagged) != 2 {
+		t.Errorf("ListByTag(\"favorites\") returned %d threads, want 2", len(tagged))
+	}
+
+	untagged, err := store.ListByTag("nonexistent")
+	if err != nil {
+		t.Fatalf("ListByTag on unused tag should not error: %v", err)
+	}
+	if len(untagged) != 0 {
+		t.Errorf("ListByTag(\"nonexistent\") = %+v, want empty", untagged)
+	}
+}
+
+func TestPrintThreadTemplateRequiresTemplate(t *testing.T) {
+	th := &Thread{ID: "thread-one", Messages: []Message{{Role: "user", Content: "hi"}}}
+
+	err := printThread(th, outputTemplate, "")
+	if err == nil {
+		t.Fatal("printThread with outputTemplate and no --template should error, got nil")
+	}
+
+	out := captureStdout(t, func() {
+		if perr := printThread(th, outputTemplate, "{{.ID}}"); perr != nil {
+			t.Fatalf("printThread: %v", perr)
+		}
+	})
+	if out != th.ID {
+		t.Errorf("printThread with a template rendered %q, want %q", out, th.ID)
+	}
+}
+
+func TestPrintThreadListTemplateRequiresTemplate(t *testing.T) {
+	all := []*Thread{{ID: "thread-one", Messages: []Message{{Role: "user", Content: "hi"}}}}
+
+	if err := printThreadList(all, outputTemplate, ""); err == nil {
+		t.Fatal("printThreadList with outputTemplate and no --template should error, got nil")
+	}
+}
+
+func TestSilentOutputRendererFinish(t *testing.T) {
+	t.Run("with content", func(t *testing.T) {
+		th := &Thread{
+			ID: "thread-one",
+			Messages: []Message{
+				{Role: "user", Content: "what's the weather"},
+				{Role: "assistant", Content: "sunny", Provider: "openai", Model: "gpt-4"},
+			},
+		}
+		r := silentOutputRenderer{format: outputJSON}
+		out := captureStdout(t, func() {
+			if err := r.finish(th, "sunny", nil); err != nil {
+				t.Fatalf("finish: %v", err)
+			}
+		})
+		var got struct {
+			Content  string `json:"content"`
+			Provider string `json:"provider"`
+			Model    string `json:"model"`
+		}
+		if err := json.Unmarshal([]byte(out), &got); err != nil {
+			t.Fatalf("unmarshal %q: %v", out, err)
+		}
+		if got.Content != "sunny" || got.Provider != "openai" || got.Model != "gpt-4" {
+			t.Errorf("finish output = %+v, want content=sunny provider=openai model=gpt-4", got)
+		}
+	})
+
+	// A provider can end its stream with zero content deltas and no error;
+	// handleCompletionResponse then appends no assistant message, so th's
+	// last message is still the user's query. finish must not mistake it
+	// for the response.
+	t.Run("empty completion", func(t *testing.T) {
+		th := &Thread{
+			ID:       "thread-two",
+			Messages: []Message{{Role: "user", Content: "what's the weather"}},
+		}
+		r := silentOutputRenderer{format: outputJSON}
+		out := captureStdout(t, func() {
+			if err := r.finish(th, "", nil); err != nil {
+				t.Fatalf("finish: %v", err)
+			}
+		})
+		var got struct {
+			Content  string `json:"content"`
+			Provider string `json:"provider"`
+			Model    string `json:"model"`
+		}
+		if err := json.Unmarshal([]byte(out), &got); err != nil {
+			t.Fatalf("unmarshal %q: %v", out, err)
+		}
+		if got.Content != "" || got.Provider != "" || got.Model != "" {
+			t.Errorf("finish with no content leaked the user message: %+v", got)
+		}
+	})
+
+	t.Run("markdown with empty completion", func(t *testing.T) {
+		th := &Thread{
+			ID:       "thread-three",
+			Messages: []Message{{Role: "user", Content: "what's the weather"}},
+		}
+		r := silentOutputRenderer{format: outputMarkdown}
+		out := captureStdout(t, func() {
+			if err := r.finish(th, "", nil); err != nil {
+				t.Fatalf("finish: %v", err)
+			}
+		})
+		if strings.Contains(out, "what's the weather") {
+			t.Errorf("finish with no content printed the user message: %q", out)
+		}
+	})
+}